@@ -3,6 +3,7 @@ package ntlm_test
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/clfs/m/ntlm"
 )
@@ -20,3 +21,13 @@ func ExampleSum() {
 	fmt.Printf("% x", ntlm.Sum(data))
 	// Output: bb bb f9 2b 7f cc 91 6f 37 7b 63 aa 50 13 2e 43
 }
+
+func ExampleSumReader() {
+	r := strings.NewReader("This page intentionally left blank.")
+	h, err := ntlm.SumReader(r)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("% x", h)
+	// Output: bb bb f9 2b 7f cc 91 6f 37 7b 63 aa 50 13 2e 43
+}
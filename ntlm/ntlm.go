@@ -2,7 +2,12 @@
 package ntlm
 
 import (
+	"crypto/subtle"
+	"encoding/hex"
 	"hash"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/md4" //lint:ignore SA1019 NTLM is backed by MD4
 )
@@ -14,6 +19,13 @@ const BlockSize = 64
 const Size = 16
 
 // New returns a new hash.Hash computing the NTLM hash.
+//
+// Data written to the hash is decoded as UTF-8 and hashed as UTF-16LE.
+// Invalid UTF-8 is hashed as U+FFFD, one per invalid byte. If the data
+// written so far ends partway through a UTF-8 sequence, Sum leaves the
+// incomplete sequence out until a later Write completes it. For such data
+// the result differs from the package-level Sum and SumReader, which hash
+// the incomplete sequence as invalid.
 func New() hash.Hash {
 	return &ntlm{
 		h: md4.New(),
@@ -21,25 +33,120 @@ func New() hash.Hash {
 }
 
 // Sum returns the NTLM hash of the data.
+//
+// Unlike the hash returned by New, Sum hashes an incomplete UTF-8 sequence
+// at the end of data as U+FFFD, one per byte, so the two can differ for
+// data that ends partway through a sequence.
 func Sum(data []byte) [Size]byte {
 	var h [Size]byte
-	hh := New()
+	hh := &ntlm{h: md4.New()}
 	hh.Write(data)
+	hh.flush()
 	hh.Sum(h[:0])
 	return h
 }
 
+// SumReader returns the NTLM hash of the data read from r until EOF.
+//
+// Like Sum, it hashes an incomplete UTF-8 sequence at EOF as U+FFFD, one
+// per byte. Copying r into the hash returned by New and calling its Sum
+// method leaves that sequence out instead.
+func SumReader(r io.Reader) ([Size]byte, error) {
+	var h [Size]byte
+	hh := &ntlm{h: md4.New()}
+	if _, err := io.Copy(hh, r); err != nil {
+		return h, err
+	}
+	hh.flush()
+	hh.Sum(h[:0])
+	return h, nil
+}
+
+// The NTLM hash of the empty password.
+var emptyHash = Sum(nil)
+
+// IsEmptyPasswordNTLM reports whether hashHex is the hex-encoded NTLM hash
+// of the empty password. The comparison is case-insensitive and runs in
+// constant time.
+func IsEmptyPasswordNTLM(hashHex string) bool {
+	b, err := hex.DecodeString(hashHex)
+	if err != nil || len(b) != Size {
+		return false
+	}
+	return subtle.ConstantTimeCompare(b, emptyHash[:]) == 1
+}
+
 type ntlm struct {
 	h hash.Hash // MD4 hasher
+
+	// An incomplete UTF-8 sequence left over from the last Write.
+	pending  [utf8.UTFMax]byte
+	npending int
+
+	// Scratch space for UTF-16LE output, kept here so Write doesn't
+	// allocate.
+	buf [512]byte
 }
 
 func (n *ntlm) Write(p []byte) (int, error) {
-	buf := make([]byte, 0, len(p)*2)
-	for _, b := range p {
-		buf = append(buf, b)
-		buf = append(buf, 0) // UTF-16 shenanigans
+	nn := len(p)
+
+	// Encode through a fixed-size buffer so long inputs don't need an
+	// intermediate allocation twice their size.
+	b := n.buf[:0]
+
+	for n.npending > 0 {
+		k := copy(n.pending[n.npending:], p)
+		q := n.pending[:n.npending+k]
+		if !utf8.FullRune(q) {
+			n.npending += k
+			return nn, nil
+		}
+		r, size := utf8.DecodeRune(q)
+		b = appendRune(b, r)
+		if size < n.npending {
+			n.npending = copy(n.pending[:], n.pending[size:n.npending])
+		} else {
+			p = p[size-n.npending:]
+			n.npending = 0
+		}
+	}
+
+	for len(p) > 0 {
+		if !utf8.FullRune(p) {
+			n.npending = copy(n.pending[:], p)
+			break
+		}
+		if len(b) > len(n.buf)-4 {
+			n.h.Write(b)
+			b = b[:0]
+		}
+		r, size := utf8.DecodeRune(p)
+		b = appendRune(b, r)
+		p = p[size:]
+	}
+
+	n.h.Write(b)
+	return nn, nil
+}
+
+// flush hashes any incomplete UTF-8 sequence as invalid.
+func (n *ntlm) flush() {
+	var buf []byte
+	for i := 0; i < n.npending; i++ {
+		buf = appendRune(buf, utf8.RuneError)
+	}
+	n.npending = 0
+	n.h.Write(buf)
+}
+
+// appendRune appends the UTF-16LE encoding of r to b.
+func appendRune(b []byte, r rune) []byte {
+	if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+		b = append(b, byte(r1), byte(r1>>8))
+		r = r2
 	}
-	return n.h.Write(buf)
+	return append(b, byte(r), byte(r>>8))
 }
 
 func (n *ntlm) Sum(b []byte) []byte {
@@ -48,6 +155,7 @@ func (n *ntlm) Sum(b []byte) []byte {
 
 func (n *ntlm) Reset() {
 	n.h.Reset()
+	n.npending = 0
 }
 
 func (n *ntlm) Size() int {
@@ -1,9 +1,16 @@
 package ntlm
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4" //lint:ignore SA1019 NTLM is backed by MD4
 )
 
 type ntlmTest struct {
@@ -42,6 +49,16 @@ var golden = []ntlmTest{
 	{"561b28dfa8ff8e6b96afd3cc9aa78339", "Even if I could be Shakespeare, I think I should still choose to be Faraday. - A. Huxley"},
 	{"39ed435fbd63b45ab92a5a67f2a0fc73", "The fugacity of a constituent in a mixture of gases at a given temperature is proportional to its mole fraction.  Lewis-Randall Rule"},
 	{"c8b2dea739380a365390d745ccca8d05", "How can you write a big system without C++?  -Paul Glick"},
+	{"e77286d072c7858e9110cc3a011d2ac8", "\u00e9"},
+	{"1ed62ea8325493edfa84564ff90fb284", "e\u0301"},
+	{"2fa86687d48c4827f48b46a31deb0746", "A\u0308\u0308x"},
+	{"b1db12409c00d1fc586fc48ecadc36a1", "caf\u00e9"},
+	{"c31b325af4969b08f44dd70cb1b85ba4", "na\u00efve"},
+	{"ced13822047f22ce2b3e7d763955f48e", "\u65e5\u672c\u8a9e"},
+	{"4b58a10cc20a4e7d808d218e1f80aabc", "\U0001f600"},
+	{"b1847a4f90ec6e6793d813f9992e54a5", "p\U0001f600ss"},
+	{"78d54ecb6cc7c823f8b6d7acf67bf657", "\U0001d11e"},
+	{"3b8ed1e676ee11996fa7a2fc4b8f30a3", "\U0001f468\u200d\U0001f469\u200d\U0001f467"},
 }
 
 // Based on the crypto/md4 test suite.
@@ -66,6 +83,193 @@ func TestGolden(t *testing.T) {
 	}
 }
 
+// Known NTLM hashes of common passwords, split the way the Pwned Passwords
+// range API expects: a 5-character prefix and a 27-character suffix of the
+// uppercase hex digest.
+var passwords = []struct {
+	in, prefix, suffix string
+}{
+	{"password", "8846F", "7EAEE8FB117AD06BDD830B7586C"},
+	{"123456", "32ED8", "7BDB5FDC5E9CBA88547376818D4"},
+	{"P@ssw0rd", "E19CC", "F75EE54E06B06A5907AF13CEF42"},
+	{"hunter2", "6608E", "4BC7B2B7A5F77CE3573570775AF"},
+	{"Passw0rd!", "FC525", "C9683E8FE067095BA2DDC971889"},
+}
+
+func TestPasswords(t *testing.T) {
+	for _, p := range passwords {
+		h := fmt.Sprintf("%X", Sum([]byte(p.in)))
+		if prefix, suffix := h[:5], h[5:]; prefix != p.prefix || suffix != p.suffix {
+			t.Errorf("ntlm(%q) = %s:%s want %s:%s", p.in, prefix, suffix, p.prefix, p.suffix)
+		}
+	}
+}
+
+func TestWriteBytes(t *testing.T) {
+	for _, g := range golden {
+		c := New()
+		for i := 0; i < len(g.in); i++ {
+			c.Write([]byte{g.in[i]})
+		}
+		if s := fmt.Sprintf("%x", c.Sum(nil)); s != g.out {
+			t.Errorf("ntlm(%q) = %s want %s", g.in, s, g.out)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	tests := []string{
+		"\xff",
+		"a\xffb",
+		"\xe2\x82",
+		"\xe2\x82a",
+		"\xf0\x9f\x98",
+		"\xed\xa0\x80", // UTF-8 encoded surrogate half
+		"\xc0\xaf",     // overlong encoding
+	}
+	for _, in := range tests {
+		// Invalid bytes hash the same as U+FFFD, as in a []rune conversion.
+		want := Sum([]byte(string([]rune(in))))
+		if got := Sum([]byte(in)); got != want {
+			t.Errorf("Sum(%q) = %x, want %x", in, got, want)
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	const want = "31d6cfe0d16ae931b73c59d7e0c089c0"
+	if s := fmt.Sprintf("%x", Sum(nil)); s != want {
+		t.Errorf("Sum(nil) = %s want %s", s, want)
+	}
+	if s := fmt.Sprintf("%x", Sum([]byte{})); s != want {
+		t.Errorf("Sum([]byte{}) = %s want %s", s, want)
+	}
+	c := New()
+	c.Write(nil)
+	c.Write([]byte{})
+	if s := fmt.Sprintf("%x", c.Sum(nil)); s != want {
+		t.Errorf("ntlm after empty writes = %s want %s", s, want)
+	}
+}
+
+func TestEmptyWriteMidRune(t *testing.T) {
+	in := "p\U0001f600ss"
+	c := New()
+	c.Write([]byte(in[:2]))
+	c.Write(nil)
+	c.Write([]byte(in[2:4]))
+	c.Write([]byte{})
+	c.Write([]byte(in[4:]))
+	if got, want := c.Sum(nil), Sum([]byte(in)); !bytes.Equal(got, want[:]) {
+		t.Errorf("ntlm(%q) with empty writes = %x want %x", in, got, want)
+	}
+}
+
+func TestIsEmptyPasswordNTLM(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"31d6cfe0d16ae931b73c59d7e0c089c0", true},
+		{"31D6CFE0D16AE931B73C59D7E0C089C0", true},
+		{"31d6cfe0d16ae931b73c59d7e0c089c1", false},
+		{"31d6cfe0d16ae931b73c59d7e0c089c", false},
+		{"31d6cfe0d16ae931b73c59d7e0c089c000", false},
+		{" 31d6cfe0d16ae931b73c59d7e0c089c0", false},
+		{"31d6cfe0d16ae931b73c59d7e0c089zz", false},
+		{"186cb09181e2c2ecaac768c47c729904", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsEmptyPasswordNTLM(tt.in); got != tt.want {
+			t.Errorf("IsEmptyPasswordNTLM(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// refSum computes the NTLM hash of s with the standard library's UTF-16
+// encoder.
+func refSum(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	h := md4.New()
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func TestLong(t *testing.T) {
+	tests := []string{
+		strings.Repeat("a", 10<<10),
+		strings.Repeat("\u00e9", 5<<10),
+		strings.Repeat("\u65e5", 4<<10),
+		strings.Repeat("p\U0001f600ss", 1<<10),
+	}
+	for _, in := range tests {
+		want := refSum(in)
+		if got := Sum([]byte(in)); !bytes.Equal(got[:], want) {
+			t.Errorf("Sum(%d bytes) = %x, want %x", len(in), got, want)
+		}
+		for _, chunk := range []int{1, 3, 511, 512, 513, 4096} {
+			c := New()
+			for i := 0; i < len(in); i += chunk {
+				j := i + chunk
+				if j > len(in) {
+					j = len(in)
+				}
+				c.Write([]byte(in[i:j]))
+			}
+			if got := c.Sum(nil); !bytes.Equal(got, want) {
+				t.Errorf("ntlm(%d bytes in chunks of %d) = %x, want %x", len(in), chunk, got, want)
+			}
+		}
+	}
+}
+
+func TestWriteAllocs(t *testing.T) {
+	c := New()
+	for _, in := range []string{"password", strings.Repeat("p\U0001f600ss", 1<<10)} {
+		p := []byte(in)
+		if n := testing.AllocsPerRun(100, func() { c.Write(p) }); n != 0 {
+			t.Errorf("Write(%d bytes) allocs = %v, want 0", len(p), n)
+		}
+	}
+}
+
+func TestSumReader(t *testing.T) {
+	for _, in := range []string{"", "abc", "p\U0001f600ss", "a\xe2\x82", "\xf0\x9f\x98a"} {
+		want := Sum([]byte(in))
+
+		// Split the input at every position, including mid-rune.
+		for i := 0; i <= len(in); i++ {
+			r := io.MultiReader(strings.NewReader(in[:i]), strings.NewReader(in[i:]))
+			got, err := SumReader(r)
+			if err != nil {
+				t.Fatalf("SumReader(%q split at %d) error: %v", in, i, err)
+			}
+			if got != want {
+				t.Fatalf("SumReader(%q split at %d) = %x, want %x", in, i, got, want)
+			}
+		}
+
+		got, err := SumReader(iotest.OneByteReader(strings.NewReader(in)))
+		if err != nil {
+			t.Fatalf("SumReader(%q one byte at a time) error: %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("SumReader(%q one byte at a time) = %x, want %x", in, got, want)
+		}
+	}
+}
+
+func TestSumReaderError(t *testing.T) {
+	errBoom := errors.New("boom")
+	if _, err := SumReader(iotest.ErrReader(errBoom)); err != errBoom {
+		t.Fatalf("SumReader error = %v, want %v", err, errBoom)
+	}
+}
+
 func TestSizes(t *testing.T) {
 	c := New()
 	if size := c.Size(); size != Size {
@@ -75,3 +279,36 @@ func TestSizes(t *testing.T) {
 		t.Fatalf("BlockSize() = %d, want %d", blockSize, BlockSize)
 	}
 }
+
+func TestSumAppends(t *testing.T) {
+	c := New()
+	io.WriteString(c, "abc")
+	want := Sum([]byte("abc"))
+	prefix := []byte{0xAA}
+	for i := 0; i < 2; i++ {
+		got := c.Sum(prefix)
+		if len(got) != 1+Size {
+			t.Fatalf("len(Sum(prefix)) = %d, want %d", len(got), 1+Size)
+		}
+		if got[0] != 0xAA {
+			t.Fatalf("Sum(prefix)[0] = %#x, want 0xaa", got[0])
+		}
+		if !bytes.Equal(got[1:], want[:]) {
+			t.Fatalf("Sum(prefix)[1:] = %x, want %x", got[1:], want)
+		}
+	}
+	if len(prefix) != 1 || prefix[0] != 0xAA {
+		t.Fatalf("Sum modified its argument: %x", prefix)
+	}
+}
+
+// The constants are hardcoded, so check them against the backing MD4 hash.
+func TestSizesMatchMD4(t *testing.T) {
+	m := md4.New()
+	if Size != m.Size() {
+		t.Fatalf("Size = %d, md4 Size() = %d", Size, m.Size())
+	}
+	if BlockSize != m.BlockSize() {
+		t.Fatalf("BlockSize = %d, md4 BlockSize() = %d", BlockSize, m.BlockSize())
+	}
+}